@@ -0,0 +1,193 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package actuation
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
+	"k8s.io/autoscaler/cluster-autoscaler/context"
+	"k8s.io/autoscaler/cluster-autoscaler/core/scaledown/deletiontracker"
+	"k8s.io/autoscaler/cluster-autoscaler/core/scaledown/status"
+	kube_client "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	v1core "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+	klog "k8s.io/klog/v2"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+)
+
+const (
+	// ScaleDownScheduled is the event reason emitted when a node is queued for deletion.
+	ScaleDownScheduled = "ScaleDownScheduled"
+	// ScaleDownAborted is the event reason emitted on a node whose scheduled deletion was aborted.
+	ScaleDownAborted = "ScaleDownAborted"
+	// ScaleDownBatched is the event reason emitted on a node that was deleted together with the rest of its
+	// atomic scale-down node group.
+	ScaleDownBatched = "ScaleDownBatched"
+)
+
+// DefaultPodEvictionHeadroom is the default Evictor.PodEvictionHeadroom: the extra time to wait after a pod's
+// terminationGracePeriod before considering its eviction done, to account for clock skew and kubelet reaction time.
+const DefaultPodEvictionHeadroom = 30 * time.Second
+
+// Evictor bundles together the pod eviction timeouts passed to NewGroupDeletionScheduler. GroupDeletionScheduler only
+// stores them: it evicts no pods itself, and only passes the drain flag on to the Batcher.
+type Evictor struct {
+	EvictionRetryTime          time.Duration
+	DsEvictionRetryTime        time.Duration
+	DsEvictionEmptyNodeTimeout time.Duration
+	PodEvictionHeadroom        time.Duration
+}
+
+// Batcher groups nodes selected for deletion and hands them off for actual removal.
+type Batcher interface {
+	// AddNodes schedules the given nodes, all belonging to nodeGroup, for deletion.
+	AddNodes(nodes []*apiv1.Node, nodeGroup cloudprovider.NodeGroup, drain bool)
+}
+
+// GroupDeletionScheduler is responsible for grouping node deletions coming from the same atomic scale-down node
+// group, so that they are only handed off to the Batcher once the whole group has been scheduled, and for aborting
+// the rest of the group if any single node in it fails.
+type GroupDeletionScheduler struct {
+	sync.Mutex
+	ctx                 *context.AutoscalingContext
+	nodeDeletionTracker *deletiontracker.NodeDeletionTracker
+	batcher             Batcher
+	evictor             Evictor
+	eventRecorder       record.EventRecorder
+
+	nodeGroups        map[string]cloudprovider.NodeGroup
+	nodeQueue         map[string][]*apiv1.Node
+	abortedNodeGroups map[string]bool
+}
+
+// NewGroupDeletionScheduler creates a new GroupDeletionScheduler. If ctx.ClientSet is set, the scheduler emits
+// ScaleDownScheduled/ScaleDownAborted/ScaleDownBatched events on the affected node objects so that operators can
+// observe the drain lifecycle via `kubectl describe node` without scraping logs.
+func NewGroupDeletionScheduler(ctx *context.AutoscalingContext, nodeDeletionTracker *deletiontracker.NodeDeletionTracker, batcher Batcher, evictor Evictor) *GroupDeletionScheduler {
+	return &GroupDeletionScheduler{
+		ctx:                 ctx,
+		nodeDeletionTracker: nodeDeletionTracker,
+		batcher:             batcher,
+		evictor:             evictor,
+		eventRecorder:       newScaleDownEventRecorder(ctx),
+		nodeGroups:          make(map[string]cloudprovider.NodeGroup),
+		nodeQueue:           make(map[string][]*apiv1.Node),
+		abortedNodeGroups:   make(map[string]bool),
+	}
+}
+
+// newScaleDownEventRecorder builds a broadcaster-backed EventRecorder bound to ctx.ClientSet. If ctx.ClientSet is
+// nil (as in some unit tests) nil is returned and recordNodeEvent no-ops instead of recording.
+func newScaleDownEventRecorder(ctx *context.AutoscalingContext) record.EventRecorder {
+	if ctx == nil || ctx.ClientSet == nil {
+		return nil
+	}
+	return buildEventRecorder(ctx.ClientSet)
+}
+
+func buildEventRecorder(kubeClient kube_client.Interface) record.EventRecorder {
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartLogging(klog.V(4).Infof)
+	eventBroadcaster.StartRecordingToSink(&v1core.EventSinkImpl{Interface: kubeClient.CoreV1().Events("")})
+	return eventBroadcaster.NewRecorder(scheme.Scheme, apiv1.EventSource{Component: "cluster-autoscaler"})
+}
+
+// ScheduleDeletion schedules the given node, belonging to nodeGroup, for deletion. If nodeGroup is atomic,
+// the node is queued until bucketSize nodes from the same group have been scheduled, at which point the whole
+// batch is handed off to the Batcher at once; otherwise the node is handed off immediately.
+func (s *GroupDeletionScheduler) ScheduleDeletion(nodeInfo *framework.NodeInfo, nodeGroup cloudprovider.NodeGroup, bucketSize int, drain bool) {
+	node := nodeInfo.Node()
+	nodeGroupId := nodeGroup.Id()
+
+	s.Lock()
+	if s.abortedNodeGroups[nodeGroupId] {
+		s.Unlock()
+		result := status.NodeDeleteResult{
+			ResultType: status.NodeDeleteErrorFailedToDelete,
+			Err:        fmt.Errorf("node group %s scale-down was aborted", nodeGroupId),
+		}
+		s.nodeDeletionTracker.EndDeletion(nodeGroupId, node.Name, result)
+		s.recordNodeEvent(node, apiv1.EventTypeWarning, ScaleDownAborted, fmt.Sprintf("couldn't schedule node for deletion: node group %s scale-down was already aborted", nodeGroupId))
+		return
+	}
+
+	atomic := false
+	if s.ctx != nil {
+		if opts, err := nodeGroup.GetOptions(s.ctx.NodeGroupDefaults); err == nil && opts != nil {
+			atomic = opts.ZeroOrMaxNodeScaling
+		}
+	}
+	if !atomic || bucketSize <= 1 {
+		s.Unlock()
+		s.recordNodeEvent(node, apiv1.EventTypeNormal, ScaleDownScheduled, "marked node for deletion")
+		s.batcher.AddNodes([]*apiv1.Node{node}, nodeGroup, drain)
+		return
+	}
+
+	s.nodeGroups[nodeGroupId] = nodeGroup
+	s.nodeQueue[nodeGroupId] = append(s.nodeQueue[nodeGroupId], node)
+	queued := s.nodeQueue[nodeGroupId]
+	s.recordNodeEvent(node, apiv1.EventTypeNormal, ScaleDownScheduled, fmt.Sprintf("marked node for deletion as part of atomic node group %s (%d/%d scheduled)", nodeGroupId, len(queued), bucketSize))
+
+	if len(queued) < bucketSize {
+		s.Unlock()
+		return
+	}
+	delete(s.nodeQueue, nodeGroupId)
+	s.Unlock()
+
+	for _, queuedNode := range queued {
+		s.recordNodeEvent(queuedNode, apiv1.EventTypeNormal, ScaleDownBatched, fmt.Sprintf("batched with %d other nodes from atomic node group %s", len(queued)-1, nodeGroupId))
+	}
+	s.batcher.AddNodes(queued, nodeGroup, drain)
+}
+
+// AbortNodeDeletion aborts the scheduled deletion of node, recording nodeDeleteResult in the deletion tracker. If
+// node belongs to an atomic node group with other nodes already queued in ScheduleDeletion, the whole group is
+// aborted and every queued peer gets the same nodeDeleteResult, cascading the failure instead of deleting a partial
+// atomic group.
+func (s *GroupDeletionScheduler) AbortNodeDeletion(node *apiv1.Node, nodeGroupId string, drain bool, reason string, nodeDeleteResult status.NodeDeleteResult) {
+	s.Lock()
+	defer s.Unlock()
+
+	klog.V(2).Infof("Aborting node deletion for %s in node group %s, reason: %s", node.Name, nodeGroupId, reason)
+	s.nodeDeletionTracker.EndDeletion(nodeGroupId, node.Name, nodeDeleteResult)
+	s.recordNodeEvent(node, apiv1.EventTypeWarning, ScaleDownAborted, reason)
+
+	queued, found := s.nodeQueue[nodeGroupId]
+	if !found {
+		return
+	}
+	s.abortedNodeGroups[nodeGroupId] = true
+	delete(s.nodeQueue, nodeGroupId)
+	for _, queuedNode := range queued {
+		s.nodeDeletionTracker.EndDeletion(nodeGroupId, queuedNode.Name, nodeDeleteResult)
+		s.recordNodeEvent(queuedNode, apiv1.EventTypeWarning, ScaleDownAborted, fmt.Sprintf("aborted: atomic node group peer %s was aborted (%s)", node.Name, reason))
+	}
+}
+
+func (s *GroupDeletionScheduler) recordNodeEvent(node *apiv1.Node, eventType, reason, message string) {
+	if s.eventRecorder == nil {
+		return
+	}
+	s.eventRecorder.Eventf(node, eventType, reason, "%s: %s", node.Name, message)
+}
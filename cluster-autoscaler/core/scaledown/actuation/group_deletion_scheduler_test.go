@@ -18,6 +18,7 @@ package actuation
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -34,6 +35,7 @@ import (
 	. "k8s.io/autoscaler/cluster-autoscaler/core/test"
 	kube_util "k8s.io/autoscaler/cluster-autoscaler/utils/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/kubernetes/pkg/scheduler/framework"
 	schedulerframework "k8s.io/kubernetes/pkg/scheduler/framework"
 )
@@ -50,6 +52,7 @@ func TestScheduleDeletion(t *testing.T) {
 		toScheduleAfterAbort  []*budgets.NodeGroupView
 		wantDeleted           int
 		wantNodeDeleteResults map[string]status.NodeDeleteResult
+		wantAbortEvents       []string
 	}{
 		{
 			name:       "no nodes",
@@ -66,6 +69,7 @@ func TestScheduleDeletion(t *testing.T) {
 				"test-node-4": {ResultType: status.NodeDeleteErrorFailedToDelete, Err: cmpopts.AnyError},
 				"test-node-5": {ResultType: status.NodeDeleteErrorFailedToDelete, Err: cmpopts.AnyError},
 			},
+			wantAbortEvents: []string{"test-node-3", "test-node-4", "test-node-5"},
 		},
 		{
 			name: "whole atomic node groups deleted",
@@ -95,6 +99,7 @@ func TestScheduleDeletion(t *testing.T) {
 				"atomic-4-node-2": {ResultType: status.NodeDeleteErrorFailedToDelete, Err: cmpopts.AnyError},
 				"atomic-4-node-3": {ResultType: status.NodeDeleteErrorFailedToDelete, Err: cmpopts.AnyError},
 			},
+			wantAbortEvents: []string{"atomic-4-node-0", "atomic-4-node-1", "atomic-4-node-2", "atomic-4-node-3"},
 		},
 	}
 	for _, tc := range testCases {
@@ -127,6 +132,8 @@ func TestScheduleDeletion(t *testing.T) {
 				t.Fatalf("Couldn't set up autoscaling context: %v", err)
 			}
 			scheduler := NewGroupDeletionScheduler(&ctx, tracker, batcher, Evictor{EvictionRetryTime: 0, DsEvictionRetryTime: 0, DsEvictionEmptyNodeTimeout: 0, PodEvictionHeadroom: DefaultPodEvictionHeadroom})
+			fakeRecorder := record.NewFakeRecorder(100)
+			scheduler.eventRecorder = fakeRecorder
 
 			if err := scheduleAll(tc.toSchedule, scheduler); err != nil {
 				t.Fatal(err)
@@ -148,10 +155,34 @@ func TestScheduleDeletion(t *testing.T) {
 			if diff := cmp.Diff(tc.wantNodeDeleteResults, gotDeletionResult, cmpopts.EquateEmpty(), cmpopts.EquateErrors()); diff != "" {
 				t.Errorf("NodeDeleteResults diff (-want +got):\n%s", diff)
 			}
+
+			gotAbortEvents := drainAbortEvents(fakeRecorder)
+			if diff := cmp.Diff(tc.wantAbortEvents, gotAbortEvents, cmpopts.EquateEmpty(), cmpopts.SortSlices(func(a, b string) bool { return a < b })); diff != "" {
+				t.Errorf("ScaleDownAborted events diff (-want +got):\n%s", diff)
+			}
 		})
 	}
 }
 
+// drainAbortEvents reads every event queued on recorder and returns the names of the nodes that got a
+// ScaleDownAborted event, so tests can assert on the abort cascade without caring about event message wording.
+func drainAbortEvents(recorder *record.FakeRecorder) []string {
+	var nodeNames []string
+	for {
+		select {
+		case event := <-recorder.Events:
+			_, rest, found := strings.Cut(event, ScaleDownAborted+" ")
+			if !found {
+				continue
+			}
+			nodeName, _, _ := strings.Cut(rest, ":")
+			nodeNames = append(nodeNames, nodeName)
+		default:
+			return nodeNames
+		}
+	}
+}
+
 type countingBatcher struct {
 	addedNodes int
 }
@@ -17,14 +17,24 @@ limitations under the License.
 package kubernetes
 
 import (
+	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	apiv1 "k8s.io/api/core/v1"
 	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
 	client "k8s.io/client-go/kubernetes"
 	v1appslister "k8s.io/client-go/listers/apps/v1"
 	v1batchlister "k8s.io/client-go/listers/batch/v1"
@@ -34,6 +44,36 @@ import (
 	podv1 "k8s.io/kubernetes/pkg/api/v1/pod"
 )
 
+// NoResyncPeriodFunc is the resync period used when a ListerRegistry builds its own SharedInformerFactory and the
+// caller hasn't overridden it with WithResyncPeriod. Autoscaler listers act on watch events rather than periodic
+// relists, so there is no need to pay for a resync, mirroring controller.NoResyncPeriodFunc().
+func NoResyncPeriodFunc() time.Duration {
+	return 0
+}
+
+// ListerRegistryOption configures optional parameters used when a ListerRegistry builds its own
+// SharedInformerFactory, e.g. via NewListerRegistryWithDefaultListers.
+type ListerRegistryOption func(*listerRegistryOptions)
+
+type listerRegistryOptions struct {
+	resyncPeriod time.Duration
+}
+
+// WithResyncPeriod overrides the resync period used when building the SharedInformerFactory backing a ListerRegistry.
+func WithResyncPeriod(resyncPeriod time.Duration) ListerRegistryOption {
+	return func(o *listerRegistryOptions) {
+		o.resyncPeriod = resyncPeriod
+	}
+}
+
+func resolveListerRegistryOptions(opts []ListerRegistryOption) listerRegistryOptions {
+	options := listerRegistryOptions{resyncPeriod: NoResyncPeriodFunc()}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
 // ListerRegistry is a registry providing various listers to list pods or nodes matching conditions
 type ListerRegistry interface {
 	AllNodeLister() NodeLister
@@ -45,6 +85,13 @@ type ListerRegistry interface {
 	JobLister() v1batchlister.JobLister
 	ReplicaSetLister() v1appslister.ReplicaSetLister
 	StatefulSetLister() v1appslister.StatefulSetLister
+	// RegisterCustomController registers a CustomControllerLister backed by a dynamic informer watching gvr, so that
+	// CustomController can later resolve owner references of kind gvk. It is meant for CRD-based workload controllers
+	// (Argo Workflow, Kubeflow TFJob, Karmada ResourceBinding/Work, kubecluster KubeCluster, ...) that the typed
+	// listers above don't know about.
+	RegisterCustomController(dynamicClient dynamic.Interface, gvk schema.GroupVersionKind, gvr schema.GroupVersionResource, stopChannel <-chan struct{}) error
+	// CustomController returns the CustomControllerLister registered for gvk via RegisterCustomController, if any.
+	CustomController(gvk schema.GroupVersionKind) (CustomControllerLister, bool)
 }
 
 type listerRegistryImpl struct {
@@ -57,6 +104,9 @@ type listerRegistryImpl struct {
 	jobLister                   v1batchlister.JobLister
 	replicaSetLister            v1appslister.ReplicaSetLister
 	statefulSetLister           v1appslister.StatefulSetLister
+
+	customControllersMu sync.RWMutex
+	customControllers   map[schema.GroupVersionKind]CustomControllerLister
 }
 
 // NewListerRegistry returns a registry providing various listers to list pods or nodes matching conditions
@@ -64,7 +114,7 @@ func NewListerRegistry(allNode NodeLister, readyNode NodeLister, allPodLister Po
 	daemonSetLister v1appslister.DaemonSetLister, replicationControllerLister v1lister.ReplicationControllerLister,
 	jobLister v1batchlister.JobLister, replicaSetLister v1appslister.ReplicaSetLister,
 	statefulSetLister v1appslister.StatefulSetLister) ListerRegistry {
-	return listerRegistryImpl{
+	return &listerRegistryImpl{
 		allNodeLister:               allNode,
 		readyNodeLister:             readyNode,
 		allPodLister:                allPodLister,
@@ -74,74 +124,246 @@ func NewListerRegistry(allNode NodeLister, readyNode NodeLister, allPodLister Po
 		jobLister:                   jobLister,
 		replicaSetLister:            replicaSetLister,
 		statefulSetLister:           statefulSetLister,
+		customControllers:           make(map[schema.GroupVersionKind]CustomControllerLister),
 	}
 }
 
-// NewListerRegistryWithDefaultListers returns a registry filled with listers of the default implementations
-func NewListerRegistryWithDefaultListers(kubeClient client.Interface, stopChannel <-chan struct{}) ListerRegistry {
-	allPodLister := NewAllPodLister(kubeClient, stopChannel)
-	readyNodeLister := NewReadyNodeLister(kubeClient, stopChannel)
-	allNodeLister := NewAllNodeLister(kubeClient, stopChannel)
-	podDisruptionBudgetLister := NewPodDisruptionBudgetLister(kubeClient, stopChannel)
-	daemonSetLister := NewDaemonSetLister(kubeClient, stopChannel)
-	replicationControllerLister := NewReplicationControllerLister(kubeClient, stopChannel)
-	jobLister := NewJobLister(kubeClient, stopChannel)
-	replicaSetLister := NewReplicaSetLister(kubeClient, stopChannel)
-	statefulSetLister := NewStatefulSetLister(kubeClient, stopChannel)
-	return NewListerRegistry(allNodeLister, readyNodeLister, allPodLister,
-		podDisruptionBudgetLister, daemonSetLister, replicationControllerLister,
-		jobLister, replicaSetLister, statefulSetLister)
+// NewListerRegistryWithDefaultListers returns a registry filled with listers of the default implementations, all
+// backed by a single SharedInformerFactory built from kubeClient. This lets callers that already run other
+// informers against the same client (webhooks, metrics servers, custom controllers) share caches and watches
+// instead of each lister opening its own ListWatch and reflector.
+func NewListerRegistryWithDefaultListers(kubeClient client.Interface, stopChannel <-chan struct{}, opts ...ListerRegistryOption) ListerRegistry {
+	options := resolveListerRegistryOptions(opts)
+	factory := informers.NewSharedInformerFactory(kubeClient, options.resyncPeriod)
+	return NewListerRegistryFromFactory(factory, stopChannel)
+}
+
+// NewListerRegistryWithFactoryAndNamespace returns a registry filled with listers of the default implementations,
+// backed by a SharedInformerFactory restricted to watching a single namespace. This is for downscoped deployments
+// whose ServiceAccount is only granted namespaced list/watch access.
+func NewListerRegistryWithFactoryAndNamespace(kubeClient client.Interface, namespace string, stopChannel <-chan struct{}, opts ...ListerRegistryOption) ListerRegistry {
+	options := resolveListerRegistryOptions(opts)
+	factory := informers.NewSharedInformerFactoryWithOptions(kubeClient, options.resyncPeriod, informers.WithNamespace(namespace))
+	return NewListerRegistryFromFactory(factory, stopChannel)
+}
+
+// NewListerRegistryFromFactory returns a registry filled with listers sourced from an already-constructed
+// SharedInformerFactory, so the caller can share it with other components. The factory is started and its caches
+// are synced before this function returns, so callers no longer race the underlying reflector goroutines.
+func NewListerRegistryFromFactory(factory informers.SharedInformerFactory, stopChannel <-chan struct{}) ListerRegistry {
+	allPodLister := &AllPodLister{podLister: factory.Core().V1().Pods().Lister()}
+	return newListerRegistryFromFactoryAndPodLister(factory, allPodLister, stopChannel)
+}
+
+// NewListerRegistryWithFilteredPodLister returns a registry identical to NewListerRegistryWithDefaultListers, except
+// its AllPodLister is a FilteredPodLister built from podListerOptions. This is for operators who want to cut down on
+// the pods watched by the autoscaler (e.g. excluding "system" namespaces) or who run with a namespaced ServiceAccount
+// and therefore need NewFilteredPodLister's per-namespace informer mode instead of a single cluster-wide ListWatch.
+func NewListerRegistryWithFilteredPodLister(kubeClient client.Interface, podListerOptions PodListerOptions, stopChannel <-chan struct{}, opts ...ListerRegistryOption) ListerRegistry {
+	options := resolveListerRegistryOptions(opts)
+	factory := informers.NewSharedInformerFactory(kubeClient, options.resyncPeriod)
+	podLister := NewFilteredPodLister(kubeClient, podListerOptions, stopChannel)
+	return newListerRegistryFromFactoryAndPodLister(factory, podLister, stopChannel)
+}
+
+// newListerRegistryFromFactoryAndPodLister builds the non-pod listers from factory and assembles a ListerRegistry
+// around them and the given podLister, starting factory and waiting for its caches to sync. It's shared between
+// NewListerRegistryFromFactory and NewListerRegistryWithFilteredPodLister so the two only differ in how they build
+// the pod lister.
+func newListerRegistryFromFactoryAndPodLister(factory informers.SharedInformerFactory, podLister PodLister, stopChannel <-chan struct{}) ListerRegistry {
+	nodes := factory.Core().V1().Nodes()
+	pdbs := factory.Policy().V1().PodDisruptionBudgets()
+	daemonSets := factory.Apps().V1().DaemonSets()
+	replicationControllers := factory.Core().V1().ReplicationControllers()
+	jobs := factory.Batch().V1().Jobs()
+	replicaSets := factory.Apps().V1().ReplicaSets()
+	statefulSets := factory.Apps().V1().StatefulSets()
+
+	// Calling Lister() registers each informer with factory, so this has to happen before factory.Start.
+	allNodeLister := &nodeListerImpl{nodeLister: nodes.Lister()}
+	readyNodeLister := &nodeListerImpl{nodeLister: nodes.Lister(), filter: IsNodeReadyAndSchedulable}
+	podDisruptionBudgetLister := &PodDisruptionBudgetListerImpl{pdbLister: pdbs.Lister()}
+
+	registry := NewListerRegistry(allNodeLister, readyNodeLister, podLister,
+		podDisruptionBudgetLister, daemonSets.Lister(), replicationControllers.Lister(),
+		jobs.Lister(), replicaSets.Lister(), statefulSets.Lister())
+
+	factory.Start(stopChannel)
+	factory.WaitForCacheSync(stopChannel)
+	return registry
 }
 
 // AllPodLister returns the AllPodLister registered to this registry
-func (r listerRegistryImpl) AllPodLister() PodLister {
+func (r *listerRegistryImpl) AllPodLister() PodLister {
 	return r.allPodLister
 }
 
 // AllNodeLister returns the AllNodeLister registered to this registry
-func (r listerRegistryImpl) AllNodeLister() NodeLister {
+func (r *listerRegistryImpl) AllNodeLister() NodeLister {
 	return r.allNodeLister
 }
 
 // ReadyNodeLister returns the ReadyNodeLister registered to this registry
-func (r listerRegistryImpl) ReadyNodeLister() NodeLister {
+func (r *listerRegistryImpl) ReadyNodeLister() NodeLister {
 	return r.readyNodeLister
 }
 
 // PodDisruptionBudgetLister returns the podDisruptionBudgetLister registered to this registry
-func (r listerRegistryImpl) PodDisruptionBudgetLister() PodDisruptionBudgetLister {
+func (r *listerRegistryImpl) PodDisruptionBudgetLister() PodDisruptionBudgetLister {
 	return r.podDisruptionBudgetLister
 }
 
 // DaemonSetLister returns the daemonSetLister registered to this registry
-func (r listerRegistryImpl) DaemonSetLister() v1appslister.DaemonSetLister {
+func (r *listerRegistryImpl) DaemonSetLister() v1appslister.DaemonSetLister {
 	return r.daemonSetLister
 }
 
 // ReplicationControllerLister returns the replicationControllerLister registered to this registry
-func (r listerRegistryImpl) ReplicationControllerLister() v1lister.ReplicationControllerLister {
+func (r *listerRegistryImpl) ReplicationControllerLister() v1lister.ReplicationControllerLister {
 	return r.replicationControllerLister
 }
 
 // JobLister returns the jobLister registered to this registry
-func (r listerRegistryImpl) JobLister() v1batchlister.JobLister {
+func (r *listerRegistryImpl) JobLister() v1batchlister.JobLister {
 	return r.jobLister
 }
 
 // ReplicaSetLister returns the replicaSetLister registered to this registry
-func (r listerRegistryImpl) ReplicaSetLister() v1appslister.ReplicaSetLister {
+func (r *listerRegistryImpl) ReplicaSetLister() v1appslister.ReplicaSetLister {
 	return r.replicaSetLister
 }
 
 // StatefulSetLister returns the statefulSetLister registered to this registry
-func (r listerRegistryImpl) StatefulSetLister() v1appslister.StatefulSetLister {
+func (r *listerRegistryImpl) StatefulSetLister() v1appslister.StatefulSetLister {
 	return r.statefulSetLister
 }
 
+// uidIndexName is the indexer name used to look up a custom controller object by its metadata.uid, since
+// CustomControllerLister.Get is keyed by an owner reference rather than a namespace/name pair.
+const uidIndexName = "uid"
+
+func uidIndexFunc(obj interface{}) ([]string, error) {
+	metaObj, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, fmt.Errorf("object has no meta: %v", err)
+	}
+	return []string{string(metaObj.GetUID())}, nil
+}
+
+// CustomControllerLister resolves the owning object of a CRD-managed pod, e.g. Karmada ResourceBinding/Work,
+// Kubeflow TFJob, Argo Workflow or kubecluster KubeCluster. Consumers of UnschedulablePods/pod-controller checks
+// should fall back to this lister when a pod's owner reference doesn't match one of the built-in controller kinds,
+// treating a successfully resolved owner as "replicated" for PDB and drain-eligibility purposes.
+type CustomControllerLister interface {
+	// Get returns the object identified by ownerRef, as tracked by this lister's dynamic informer.
+	Get(ownerRef metav1.OwnerReference) (metav1.Object, error)
+}
+
+type customControllerLister struct {
+	gvk     schema.GroupVersionKind
+	indexer cache.Indexer
+}
+
+// Get implements CustomControllerLister.
+func (l *customControllerLister) Get(ownerRef metav1.OwnerReference) (metav1.Object, error) {
+	if ownerRef.Kind != l.gvk.Kind {
+		return nil, fmt.Errorf("owner reference kind %q does not match registered kind %q", ownerRef.Kind, l.gvk.Kind)
+	}
+	objs, err := l.indexer.ByIndex(uidIndexName, string(ownerRef.UID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up %s %s/%s: %v", l.gvk.Kind, ownerRef.Name, ownerRef.UID, err)
+	}
+	if len(objs) == 0 {
+		return nil, fmt.Errorf("%s %q (uid %s) not found", l.gvk.Kind, ownerRef.Name, ownerRef.UID)
+	}
+	unstructuredObj, ok := objs[0].(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("unexpected object type %T for %s %q", objs[0], l.gvk.Kind, ownerRef.Name)
+	}
+	return unstructuredObj, nil
+}
+
+// RegisterCustomController registers a CustomControllerLister for gvk, backed by a dynamic informer watching gvr.
+// It starts its own dynamicinformer.NewFilteredDynamicSharedInformerFactory and waits for its cache to sync before
+// returning, mirroring NewListerRegistryFromFactory's cache-sync guarantee for the built-in listers.
+func (r *listerRegistryImpl) RegisterCustomController(dynamicClient dynamic.Interface, gvk schema.GroupVersionKind, gvr schema.GroupVersionResource, stopChannel <-chan struct{}) error {
+	factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(dynamicClient, NoResyncPeriodFunc(), apiv1.NamespaceAll, nil)
+	informer := factory.ForResource(gvr).Informer()
+	if err := informer.AddIndexers(cache.Indexers{uidIndexName: uidIndexFunc}); err != nil {
+		return fmt.Errorf("failed to add uid indexer for %s: %v", gvk, err)
+	}
+
+	factory.Start(stopChannel)
+	factory.WaitForCacheSync(stopChannel)
+
+	r.customControllersMu.Lock()
+	defer r.customControllersMu.Unlock()
+	if r.customControllers == nil {
+		r.customControllers = make(map[schema.GroupVersionKind]CustomControllerLister)
+	}
+	r.customControllers[gvk] = &customControllerLister{gvk: gvk, indexer: informer.GetIndexer()}
+	return nil
+}
+
+// CustomController returns the CustomControllerLister registered for gvk via RegisterCustomController, if any.
+func (r *listerRegistryImpl) CustomController(gvk schema.GroupVersionKind) (CustomControllerLister, bool) {
+	r.customControllersMu.RLock()
+	defer r.customControllersMu.RUnlock()
+	l, ok := r.customControllers[gvk]
+	return l, ok
+}
+
+// ParseCustomControllersFlag parses the --custom-controllers flag value, a comma-separated list of
+// "group/version/Kind=group/version/resource" pairs, into the GVK->GVR map consumed by RegisterCustomController.
+// Registering the flag itself and calling RegisterCustomController/CustomController from the pod-controller and
+// drain-eligibility checks is done by their respective packages, none of which are part of this checkout.
+func ParseCustomControllersFlag(value string) (map[schema.GroupVersionKind]schema.GroupVersionResource, error) {
+	result := make(map[schema.GroupVersionKind]schema.GroupVersionResource)
+	if value == "" {
+		return result, nil
+	}
+	for _, pair := range strings.Split(value, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid --custom-controllers entry %q: expected group/version/Kind=group/version/resource", pair)
+		}
+		gvk, err := parseGroupVersionKind(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid --custom-controllers entry %q: %v", pair, err)
+		}
+		gvr, err := parseGroupVersionResource(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid --custom-controllers entry %q: %v", pair, err)
+		}
+		result[gvk] = gvr
+	}
+	return result, nil
+}
+
+func parseGroupVersionKind(s string) (schema.GroupVersionKind, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 3 {
+		return schema.GroupVersionKind{}, fmt.Errorf("expected group/version/Kind, got %q", s)
+	}
+	return schema.GroupVersionKind{Group: parts[0], Version: parts[1], Kind: parts[2]}, nil
+}
+
+func parseGroupVersionResource(s string) (schema.GroupVersionResource, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 3 {
+		return schema.GroupVersionResource{}, fmt.Errorf("expected group/version/resource, got %q", s)
+	}
+	return schema.GroupVersionResource{Group: parts[0], Version: parts[1], Resource: parts[2]}, nil
+}
+
 // PodLister lists all pods.
 // To filter out the scheduled or unschedulable pods the helper methods ScheduledPods and UnschedulablePods should be used.
 type PodLister interface {
 	List() ([]*apiv1.Pod, error)
+	// ListByNamespace returns all pods in namespace.
+	ListByNamespace(namespace string) ([]*apiv1.Pod, error)
+	// ListWithSelector returns all pods matching selector.
+	ListWithSelector(selector labels.Selector) ([]*apiv1.Pod, error)
 }
 
 // ScheduledPods is a helper method that returns all scheduled pods from given pod list.
@@ -182,6 +404,16 @@ func (lister *AllPodLister) List() ([]*apiv1.Pod, error) {
 	return lister.podLister.List(labels.Everything())
 }
 
+// ListByNamespace returns all pods in namespace.
+func (lister *AllPodLister) ListByNamespace(namespace string) ([]*apiv1.Pod, error) {
+	return lister.podLister.Pods(namespace).List(labels.Everything())
+}
+
+// ListWithSelector returns all pods matching selector.
+func (lister *AllPodLister) ListWithSelector(selector labels.Selector) ([]*apiv1.Pod, error) {
+	return lister.podLister.List(selector)
+}
+
 // NewAllPodLister builds AllPodLister
 func NewAllPodLister(kubeClient client.Interface, stopchannel <-chan struct{}) PodLister {
 	selector := fields.ParseSelectorOrDie("status.phase!=" +
@@ -196,6 +428,171 @@ func NewAllPodLister(kubeClient client.Interface, stopchannel <-chan struct{}) P
 	}
 }
 
+// PodListerOptions configures NewFilteredPodLister.
+type PodListerOptions struct {
+	// IncludeNamespaces restricts the lister to only these namespaces. Mutually exclusive with ExcludeNamespaces;
+	// if both are set, IncludeNamespaces wins.
+	IncludeNamespaces []string
+	// ExcludeNamespaces filters these namespaces out of the results. Ignored if IncludeNamespaces is set.
+	ExcludeNamespaces []string
+	// LabelSelector, if set, is applied server-side to the pod ListWatch(es) backing this lister. Defaults to
+	// labels.Everything().
+	LabelSelector labels.Selector
+	// PerNamespaceInformers, when true and IncludeNamespaces is non-empty, starts one SharedIndexInformer per
+	// included namespace instead of a single cluster-wide ListWatch filtered client-side by namespace. Use this
+	// when RBAC only grants namespaced list/watch, e.g. a namespaced ServiceAccount in a multi-tenant or
+	// virtual-kubelet setup.
+	PerNamespaceInformers bool
+}
+
+// FilteredPodLister lists pods restricted to a set of namespaces and/or a label selector, as configured by
+// PodListerOptions. Use NewFilteredPodLister to build one.
+type FilteredPodLister struct {
+	podLister         v1lister.PodLister
+	namespaceListers  map[string]v1lister.PodNamespaceLister
+	includeNamespaces map[string]bool
+	excludeNamespaces map[string]bool
+}
+
+// List returns all pods allowed by this lister's namespace and label filters.
+func (lister *FilteredPodLister) List() ([]*apiv1.Pod, error) {
+	if lister.namespaceListers != nil {
+		return lister.listAllWatchedNamespaces(labels.Everything())
+	}
+	pods, err := lister.podLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	return filterPodsByNamespace(pods, lister.includeNamespaces, lister.excludeNamespaces), nil
+}
+
+// ListByNamespace returns all pods in namespace allowed by this lister's label filter. It returns an error if
+// namespace has no informer of its own (PerNamespaceInformers mode) or is filtered out by IncludeNamespaces or
+// ExcludeNamespaces.
+func (lister *FilteredPodLister) ListByNamespace(namespace string) ([]*apiv1.Pod, error) {
+	if lister.namespaceListers != nil {
+		nsLister, found := lister.namespaceListers[namespace]
+		if !found {
+			return nil, fmt.Errorf("namespace %q is not watched by this lister", namespace)
+		}
+		return nsLister.List(labels.Everything())
+	}
+	if !lister.namespaceAllowed(namespace) {
+		return nil, fmt.Errorf("namespace %q is excluded from this lister", namespace)
+	}
+	return lister.podLister.Pods(namespace).List(labels.Everything())
+}
+
+// namespaceAllowed reports whether namespace passes this lister's IncludeNamespaces/ExcludeNamespaces filter.
+func (lister *FilteredPodLister) namespaceAllowed(namespace string) bool {
+	if len(lister.includeNamespaces) > 0 && !lister.includeNamespaces[namespace] {
+		return false
+	}
+	if len(lister.excludeNamespaces) > 0 && lister.excludeNamespaces[namespace] {
+		return false
+	}
+	return true
+}
+
+// ListWithSelector returns all pods allowed by this lister's namespace filter and matching selector.
+func (lister *FilteredPodLister) ListWithSelector(selector labels.Selector) ([]*apiv1.Pod, error) {
+	if lister.namespaceListers != nil {
+		return lister.listAllWatchedNamespaces(selector)
+	}
+	pods, err := lister.podLister.List(selector)
+	if err != nil {
+		return nil, err
+	}
+	return filterPodsByNamespace(pods, lister.includeNamespaces, lister.excludeNamespaces), nil
+}
+
+func (lister *FilteredPodLister) listAllWatchedNamespaces(selector labels.Selector) ([]*apiv1.Pod, error) {
+	var allPods []*apiv1.Pod
+	for namespace, nsLister := range lister.namespaceListers {
+		pods, err := nsLister.List(selector)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pods in namespace %s: %v", namespace, err)
+		}
+		allPods = append(allPods, pods...)
+	}
+	return allPods, nil
+}
+
+func filterPodsByNamespace(pods []*apiv1.Pod, includeNamespaces, excludeNamespaces map[string]bool) []*apiv1.Pod {
+	if len(includeNamespaces) == 0 && len(excludeNamespaces) == 0 {
+		return pods
+	}
+	var filtered []*apiv1.Pod
+	for _, pod := range pods {
+		if len(includeNamespaces) > 0 && !includeNamespaces[pod.Namespace] {
+			continue
+		}
+		if len(excludeNamespaces) > 0 && excludeNamespaces[pod.Namespace] {
+			continue
+		}
+		filtered = append(filtered, pod)
+	}
+	return filtered
+}
+
+func toNamespaceSet(namespaces []string) map[string]bool {
+	if len(namespaces) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(namespaces))
+	for _, namespace := range namespaces {
+		set[namespace] = true
+	}
+	return set
+}
+
+// NewFilteredPodLister builds a FilteredPodLister according to opts. Like NewAllPodLister, it only watches pods
+// whose status.phase isn't Succeeded or Failed.
+func NewFilteredPodLister(kubeClient client.Interface, opts PodListerOptions, stopChannel <-chan struct{}) PodLister {
+	selector := opts.LabelSelector
+	if selector == nil {
+		selector = labels.Everything()
+	}
+	fieldSelector := fields.ParseSelectorOrDie("status.phase!=" +
+		string(apiv1.PodSucceeded) + ",status.phase!=" + string(apiv1.PodFailed))
+	tweakListOptions := func(options *metav1.ListOptions) {
+		options.FieldSelector = fieldSelector.String()
+		options.LabelSelector = selector.String()
+	}
+
+	if opts.PerNamespaceInformers && len(opts.IncludeNamespaces) > 0 {
+		namespaceListers := make(map[string]v1lister.PodNamespaceLister, len(opts.IncludeNamespaces))
+		for _, namespace := range opts.IncludeNamespaces {
+			factory := informers.NewSharedInformerFactoryWithOptions(kubeClient, NoResyncPeriodFunc(),
+				informers.WithNamespace(namespace), informers.WithTweakListOptions(tweakListOptions))
+			podLister := factory.Core().V1().Pods().Lister()
+			factory.Start(stopChannel)
+			factory.WaitForCacheSync(stopChannel)
+			namespaceListers[namespace] = podLister.Pods(namespace)
+		}
+		return &FilteredPodLister{namespaceListers: namespaceListers}
+	}
+
+	factory := informers.NewSharedInformerFactoryWithOptions(kubeClient, NoResyncPeriodFunc(),
+		informers.WithTweakListOptions(tweakListOptions))
+	podLister := factory.Core().V1().Pods().Lister()
+	factory.Start(stopChannel)
+	factory.WaitForCacheSync(stopChannel)
+
+	includeNamespaces := toNamespaceSet(opts.IncludeNamespaces)
+	excludeNamespaces := toNamespaceSet(opts.ExcludeNamespaces)
+	if len(includeNamespaces) > 0 {
+		// IncludeNamespaces and ExcludeNamespaces are mutually exclusive; IncludeNamespaces wins.
+		excludeNamespaces = nil
+	}
+
+	return &FilteredPodLister{
+		podLister:         podLister,
+		includeNamespaces: includeNamespaces,
+		excludeNamespaces: excludeNamespaces,
+	}
+}
+
 // NodeLister lists nodes.
 type NodeLister interface {
 	List() ([]*apiv1.Node, error)
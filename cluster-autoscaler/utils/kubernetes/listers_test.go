@@ -0,0 +1,329 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestNewListerRegistryFromFactory(t *testing.T) {
+	node := &apiv1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}
+	pod := &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod-1", Namespace: "default"}}
+	kubeClient := fake.NewSimpleClientset(node, pod)
+	factory := informers.NewSharedInformerFactory(kubeClient, NoResyncPeriodFunc())
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	registry := NewListerRegistryFromFactory(factory, stopCh)
+
+	nodes, err := registry.AllNodeLister().List()
+	if err != nil {
+		t.Fatalf("AllNodeLister().List() returned unexpected error: %v", err)
+	}
+	if len(nodes) != 1 || nodes[0].Name != "node-1" {
+		t.Errorf("AllNodeLister().List() = %v, want a single node named node-1", nodes)
+	}
+
+	pods, err := registry.AllPodLister().List()
+	if err != nil {
+		t.Fatalf("AllPodLister().List() returned unexpected error: %v", err)
+	}
+	if len(pods) != 1 || pods[0].Name != "pod-1" {
+		t.Errorf("AllPodLister().List() = %v, want a single pod named pod-1", pods)
+	}
+}
+
+func TestNewListerRegistryWithFactoryAndNamespace(t *testing.T) {
+	podA := &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod-a", Namespace: "team-a"}}
+	podB := &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod-b", Namespace: "team-b"}}
+	kubeClient := fake.NewSimpleClientset(podA, podB)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	registry := NewListerRegistryWithFactoryAndNamespace(kubeClient, "team-a", stopCh)
+
+	pods, err := registry.AllPodLister().List()
+	if err != nil {
+		t.Fatalf("AllPodLister().List() returned unexpected error: %v", err)
+	}
+	if len(pods) != 1 || pods[0].Name != "pod-a" {
+		t.Errorf("AllPodLister().List() = %v, want only pod-a from namespace team-a", pods)
+	}
+}
+
+// waitForPodCount polls list until it returns want pods or 2s pass, so the assertions don't depend on exactly when
+// the lister's informer cache catches up with the fake clientset.
+func waitForPodCount(t *testing.T, list func() ([]*apiv1.Pod, error), want int) []*apiv1.Pod {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		pods, err := list()
+		if err != nil {
+			t.Fatalf("list() returned unexpected error: %v", err)
+		}
+		if len(pods) == want {
+			return pods
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for lister to sync: got %d pods, want %d", len(pods), want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestFilteredPodListerNamespaceFilter(t *testing.T) {
+	podA := &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod-a", Namespace: "team-a"}}
+	podB := &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod-b", Namespace: "team-b"}}
+	podKubeSystem := &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod-ks", Namespace: "kube-system"}}
+	kubeClient := fake.NewSimpleClientset(podA, podB, podKubeSystem)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	lister := NewFilteredPodLister(kubeClient, PodListerOptions{ExcludeNamespaces: []string{"kube-system"}}, stopCh)
+
+	waitForPodCount(t, lister.List, 2)
+
+	if _, err := lister.ListByNamespace("kube-system"); err == nil {
+		t.Error("ListByNamespace(\"kube-system\") returned no error for an excluded namespace")
+	}
+
+	teamAPods := waitForPodCount(t, func() ([]*apiv1.Pod, error) { return lister.ListByNamespace("team-a") }, 1)
+	if teamAPods[0].Name != "pod-a" {
+		t.Errorf("ListByNamespace(\"team-a\") = %v, want only pod-a", teamAPods)
+	}
+}
+
+func TestFilteredPodListerIncludeWinsOverExclude(t *testing.T) {
+	podA := &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod-a", Namespace: "team-a"}}
+	podB := &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod-b", Namespace: "team-b"}}
+	kubeClient := fake.NewSimpleClientset(podA, podB)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	// IncludeNamespaces and ExcludeNamespaces are mutually exclusive; IncludeNamespaces should win, so team-a must
+	// still show up even though it's also listed in ExcludeNamespaces.
+	lister := NewFilteredPodLister(kubeClient, PodListerOptions{
+		IncludeNamespaces: []string{"team-a"},
+		ExcludeNamespaces: []string{"team-a"},
+	}, stopCh)
+
+	pods := waitForPodCount(t, lister.List, 1)
+	if pods[0].Name != "pod-a" {
+		t.Errorf("List() = %v, want only pod-a since IncludeNamespaces should win over ExcludeNamespaces", pods)
+	}
+}
+
+func TestFilteredPodListerLabelSelector(t *testing.T) {
+	matching := &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod-match", Namespace: "default", Labels: map[string]string{"tier": "frontend"}}}
+	other := &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod-other", Namespace: "default", Labels: map[string]string{"tier": "backend"}}}
+	kubeClient := fake.NewSimpleClientset(matching, other)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	lister := NewFilteredPodLister(kubeClient, PodListerOptions{LabelSelector: labels.SelectorFromSet(labels.Set{"tier": "frontend"})}, stopCh)
+
+	pods := waitForPodCount(t, lister.List, 1)
+	if pods[0].Name != "pod-match" {
+		t.Errorf("List() = %v, want only pod-match", pods)
+	}
+}
+
+func TestFilteredPodListerPerNamespaceInformers(t *testing.T) {
+	podA := &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod-a", Namespace: "team-a"}}
+	podB := &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod-b", Namespace: "team-b"}}
+	kubeClient := fake.NewSimpleClientset(podA, podB)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	lister := NewFilteredPodLister(kubeClient, PodListerOptions{
+		IncludeNamespaces:     []string{"team-a"},
+		PerNamespaceInformers: true,
+	}, stopCh)
+
+	pods, err := lister.List()
+	if err != nil {
+		t.Fatalf("List() returned unexpected error: %v", err)
+	}
+	if len(pods) != 1 || pods[0].Name != "pod-a" {
+		t.Errorf("List() = %v, want only pod-a from the watched namespace team-a", pods)
+	}
+
+	if _, err := lister.ListByNamespace("team-b"); err == nil {
+		t.Error("ListByNamespace(\"team-b\") returned no error for a namespace with no informer")
+	}
+}
+
+func newWorkflow(namespace, name, uid string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "argoproj.io/v1alpha1",
+			"kind":       "Workflow",
+			"metadata": map[string]interface{}{
+				"namespace": namespace,
+				"name":      name,
+				"uid":       uid,
+			},
+		},
+	}
+}
+
+func TestCustomControllerListerGet(t *testing.T) {
+	workflowGvk := schema.GroupVersionKind{Group: "argoproj.io", Version: "v1alpha1", Kind: "Workflow"}
+	workflow := newWorkflow("default", "hello-world", "wf-uid-1")
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{uidIndexName: uidIndexFunc})
+	if err := indexer.Add(workflow); err != nil {
+		t.Fatalf("Couldn't add workflow to indexer: %v", err)
+	}
+	lister := &customControllerLister{gvk: workflowGvk, indexer: indexer}
+
+	t.Run("found", func(t *testing.T) {
+		obj, err := lister.Get(metav1.OwnerReference{Kind: "Workflow", Name: "hello-world", UID: types.UID("wf-uid-1")})
+		if err != nil {
+			t.Fatalf("Get() returned unexpected error: %v", err)
+		}
+		if obj.GetName() != "hello-world" {
+			t.Errorf("Get() returned object named %q, want %q", obj.GetName(), "hello-world")
+		}
+	})
+
+	t.Run("kind mismatch", func(t *testing.T) {
+		if _, err := lister.Get(metav1.OwnerReference{Kind: "TFJob", Name: "hello-world", UID: types.UID("wf-uid-1")}); err == nil {
+			t.Error("Get() with mismatched kind returned no error")
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		if _, err := lister.Get(metav1.OwnerReference{Kind: "Workflow", Name: "missing", UID: types.UID("does-not-exist")}); err == nil {
+			t.Error("Get() for an unknown uid returned no error")
+		}
+	})
+}
+
+func TestRegisterCustomController(t *testing.T) {
+	workflowGvk := schema.GroupVersionKind{Group: "argoproj.io", Version: "v1alpha1", Kind: "Workflow"}
+	workflowGvr := schema.GroupVersionResource{Group: "argoproj.io", Version: "v1alpha1", Resource: "workflows"}
+	workflow := newWorkflow("default", "hello-world", "wf-uid-1")
+
+	scheme := runtime.NewScheme()
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(scheme,
+		map[schema.GroupVersionResource]string{workflowGvr: "WorkflowList"}, workflow)
+
+	registry := &listerRegistryImpl{customControllers: make(map[schema.GroupVersionKind]CustomControllerLister)}
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	if err := registry.RegisterCustomController(dynamicClient, workflowGvk, workflowGvr, stopCh); err != nil {
+		t.Fatalf("RegisterCustomController() returned unexpected error: %v", err)
+	}
+
+	lister, found := registry.CustomController(workflowGvk)
+	if !found {
+		t.Fatal("CustomController() didn't find the just-registered gvk")
+	}
+	obj, err := lister.Get(metav1.OwnerReference{Kind: "Workflow", Name: "hello-world", UID: types.UID("wf-uid-1")})
+	if err != nil {
+		t.Fatalf("Get() returned unexpected error: %v", err)
+	}
+	if obj.GetName() != "hello-world" {
+		t.Errorf("Get() returned object named %q, want %q", obj.GetName(), "hello-world")
+	}
+
+	if _, found := registry.CustomController(schema.GroupVersionKind{Group: "other.io", Version: "v1", Kind: "Other"}); found {
+		t.Error("CustomController() found a lister for a gvk that was never registered")
+	}
+}
+
+func TestParseCustomControllersFlag(t *testing.T) {
+	testCases := []struct {
+		name    string
+		value   string
+		want    map[schema.GroupVersionKind]schema.GroupVersionResource
+		wantErr bool
+	}{
+		{
+			name:  "empty",
+			value: "",
+			want:  map[schema.GroupVersionKind]schema.GroupVersionResource{},
+		},
+		{
+			name:  "single entry",
+			value: "argoproj.io/v1alpha1/Workflow=argoproj.io/v1alpha1/workflows",
+			want: map[schema.GroupVersionKind]schema.GroupVersionResource{
+				{Group: "argoproj.io", Version: "v1alpha1", Kind: "Workflow"}: {Group: "argoproj.io", Version: "v1alpha1", Resource: "workflows"},
+			},
+		},
+		{
+			name:  "multiple entries",
+			value: "argoproj.io/v1alpha1/Workflow=argoproj.io/v1alpha1/workflows,kubeflow.org/v1/TFJob=kubeflow.org/v1/tfjobs",
+			want: map[schema.GroupVersionKind]schema.GroupVersionResource{
+				{Group: "argoproj.io", Version: "v1alpha1", Kind: "Workflow"}: {Group: "argoproj.io", Version: "v1alpha1", Resource: "workflows"},
+				{Group: "kubeflow.org", Version: "v1", Kind: "TFJob"}:         {Group: "kubeflow.org", Version: "v1", Resource: "tfjobs"},
+			},
+		},
+		{
+			name:    "missing equals",
+			value:   "argoproj.io/v1alpha1/Workflow",
+			wantErr: true,
+		},
+		{
+			name:    "malformed gvk",
+			value:   "argoproj.io/Workflow=argoproj.io/v1alpha1/workflows",
+			wantErr: true,
+		},
+		{
+			name:    "malformed gvr",
+			value:   "argoproj.io/v1alpha1/Workflow=argoproj.io/workflows",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseCustomControllersFlag(tc.value)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("ParseCustomControllersFlag() returned no error, want one")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseCustomControllersFlag() returned unexpected error: %v", err)
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("ParseCustomControllersFlag() = %v, want %v", got, tc.want)
+			}
+			for gvk, gvr := range tc.want {
+				if got[gvk] != gvr {
+					t.Errorf("ParseCustomControllersFlag()[%v] = %v, want %v", gvk, got[gvk], gvr)
+				}
+			}
+		})
+	}
+}